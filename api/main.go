@@ -1,17 +1,18 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/aws/aws-sdk-go/service/s3/s3manager"
@@ -30,11 +31,17 @@ var (
 	listenPort          = os.Getenv("PORT")                    // Port to listen on, defaults to "8080"
 	awsEndpoint         = os.Getenv("AWS_ENDPOINT")            // MinIO endpoint, e.g., "http://minio:9000"
 	awsS3ForcePathStyle = os.Getenv("AWS_S3_FORCE_PATH_STYLE") // "true" for MinIO
+
+	storageDriver        = os.Getenv("STORAGE_DRIVER")       // "s3" (default) or "local"
+	localStorageRoot     = os.Getenv("LOCAL_STORAGE_ROOT")   // root directory for the local driver
+	localMaxDiskBytesEnv = os.Getenv("LOCAL_MAX_DISK_BYTES") // optional quota for the local driver, in bytes
 )
 
 var (
 	s3Client   *s3.S3
 	s3Uploader *s3manager.Uploader
+
+	storageBackend Storage
 )
 
 const (
@@ -43,9 +50,11 @@ const (
 )
 
 type FileInfo struct {
-	Filename   string    `json:"filename"`
-	Path       string    `json:"path"`
-	UploadedAt time.Time `json:"uploaded_at"`
+	Filename      string    `json:"filename"`
+	Path          string    `json:"path"`
+	UploadedAt    time.Time `json:"uploaded_at"`
+	ThumbnailPath string    `json:"thumbnail_path,omitempty"`
+	PreviewPath   string    `json:"preview_path,omitempty"`
 }
 
 // initS3Client initializes the AWS S3 session and client.
@@ -79,16 +88,48 @@ func initS3Client() {
 	log.Println("S3 client initialized successfully.")
 }
 
+// initStorageBackend selects and initializes the Storage implementation
+// backing the file handlers, based on STORAGE_DRIVER.
+func initStorageBackend() {
+	switch strings.ToLower(storageDriver) {
+	case "local":
+		if localStorageRoot == "" {
+			localStorageRoot = "./data"
+		}
+		var maxDiskBytes int64
+		if localMaxDiskBytesEnv != "" {
+			parsed, err := strconv.ParseInt(localMaxDiskBytesEnv, 10, 64)
+			if err != nil {
+				log.Fatalf("Invalid LOCAL_MAX_DISK_BYTES value %q: %v", localMaxDiskBytesEnv, err)
+			}
+			maxDiskBytes = parsed
+		}
+
+		backend, err := newLocalStorage(localStorageRoot, maxDiskBytes)
+		if err != nil {
+			log.Fatalf("Failed to initialize local storage backend: %v", err)
+		}
+		storageBackend = backend
+		log.Printf("Local storage backend initialized at %s (quota: %d bytes)", localStorageRoot, maxDiskBytes)
+	case "", "s3":
+		initS3Client()
+		storageBackend = newS3Storage(s3Client, s3Uploader, s3Bucket)
+	default:
+		log.Fatalf("Unknown STORAGE_DRIVER %q, expected \"s3\" or \"local\".", storageDriver)
+	}
+}
+
 // @title File Storage Service API
 // @version 1.0
-// @description A simple microservice for storing and retrieving files, with S3/MinIO backend.
+// @description A simple microservice for storing and retrieving files, with a pluggable S3/MinIO or local-disk backend.
 // @BasePath /
 func main() {
 	if listenPort == "" {
 		listenPort = "8080"
 	}
 
-	initS3Client()
+	initStorageBackend()
+	initNotificationPublisher()
 	e := echo.New()
 	e.Use(middleware.Logger())  // Request logging
 	e.Use(middleware.Recover()) // Recover from panics
@@ -100,8 +141,21 @@ func main() {
 	e.GET("/swagger/*", echoSwagger.WrapHandler)
 	e.GET("/files", listFilesHandler)
 	e.POST("/files", uploadFilesHandler)
-	e.GET("/files/:filename", getFileHandler)
-	e.DELETE("/files/:filename", deleteFileHandler)
+	e.POST("/files/presign/upload", presignUploadHandler)
+	e.POST("/files/multipart", initiateMultipartUploadHandler)
+	e.PUT("/files/multipart/:id/:partNum", uploadPartHandler)
+	e.GET("/files/multipart/:id", listPartsHandler)
+	e.POST("/files/multipart/:id/complete", completeMultipartUploadHandler)
+	e.DELETE("/files/multipart/:id", abortMultipartUploadHandler)
+	e.GET("/files/thumb/*", getThumbnailHandler)
+	e.GET("/files/preview/*", getPreviewHandler)
+	e.GET("/files/presign/*", presignDownloadHandler)
+	e.POST("/files/share/*", shareFileHandler)
+	// Object keys are namespaced as users/{user}/... or shared/... and may
+	// themselves contain slashes, so the key is captured via the trailing
+	// wildcard rather than a single :filename segment.
+	e.GET("/files/*", getFileHandler)
+	e.DELETE("/files/*", deleteFileHandler)
 	log.Printf("Starting Go File Storage Service on :%s", listenPort)
 	if err := e.Start(":" + listenPort); err != nil && err != http.ErrServerClosed {
 		log.Fatalf("Server failed to start: %v", err)
@@ -130,9 +184,11 @@ func healthCheckHandler(c echo.Context) error {
 	return c.JSON(http.StatusOK, map[string]string{"status": "healthy"})
 }
 
-// listFilesHandler lists all objects in the configured S3 bucket.
+// listFilesHandler lists the objects the caller may see: their own
+// users/{user}/... objects, plus any shared/... objects they own or whose
+// ACL names them. An admin-role request (see isAdminRequest) sees every object.
 // @Summary List all files
-// @Description Lists all available files in the S3 bucket. Assumes authentication/authorization by gateway.
+// @Description Lists the files owned by the caller plus any shared files they've been granted access to. An admin-role caller sees every file. Assumes authentication/authorization by gateway.
 // @Tags Files
 // @Produce json
 // @Success 200 {array} FileInfo "List of files"
@@ -144,36 +200,62 @@ func listFilesHandler(c echo.Context) error {
 		userPreferredUsername = "N/A (no X-User-Preferred-Username header)"
 	}
 
-	input := &s3.ListObjectsV2Input{
-		Bucket: aws.String(s3Bucket),
+	admin := isAdminRequest(c)
+	prefix := ""
+	if !admin {
+		prefix = userPrefix(userPreferredUsername)
 	}
 
-	result, err := s3Client.ListObjectsV2(input)
+	objects, err := storageBackend.List(c.Request().Context(), prefix)
 	if err != nil {
-		log.Printf("Error listing S3 objects for user %s: %v", userPreferredUsername, err)
+		log.Printf("Error listing objects for user %s: %v", userPreferredUsername, err)
 		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to list files from storage.")
 	}
+	if !admin {
+		shared, err := storageBackend.List(c.Request().Context(), sharedPrefix)
+		if err != nil {
+			log.Printf("Error listing shared objects for user %s: %v", userPreferredUsername, err)
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to list files from storage.")
+		}
+		for _, obj := range shared {
+			// List results carry no metadata, so owner and ACL come from Stat.
+			info, err := storageBackend.Stat(c.Request().Context(), obj.Key)
+			if err != nil {
+				continue
+			}
+			if checkReadAccess(info, userPreferredUsername, false) == nil {
+				objects = append(objects, obj)
+			}
+		}
+	}
 
 	var files []FileInfo
-	for _, obj := range result.Contents {
-		if obj.Key != nil && !strings.HasSuffix(*obj.Key, "/") {
-			files = append(files, FileInfo{
-				Filename:   *obj.Key,
-				Path:       fmt.Sprintf("/files/%s", *obj.Key),
-				UploadedAt: *obj.LastModified,
-			})
+	for _, obj := range objects {
+		if strings.HasSuffix(obj.Key, "/") || isDerivativeKey(obj.Key) {
+			continue
+		}
+		info := FileInfo{
+			Filename:   obj.Key,
+			Path:       fmt.Sprintf("/files/%s", obj.Key),
+			UploadedAt: obj.LastModified,
+		}
+		if isImageKey(obj.Key) {
+			info.ThumbnailPath = fmt.Sprintf("/files/thumb/%s", obj.Key)
+			info.PreviewPath = fmt.Sprintf("/files/preview/%s", obj.Key)
 		}
+		files = append(files, info)
 	}
 	log.Printf("Files listed successfully for user: %s", userPreferredUsername)
 	return c.JSON(http.StatusOK, files)
 }
 
-// uploadFilesHandler handles uploading one or more files to S3.
+// uploadFilesHandler handles uploading one or more files to the storage backend.
 // @Summary Upload files
-// @Description Uploads one or more files to the service. Assumes authentication/authorization by gateway.
+// @Description Uploads one or more files to the service, namespaced under the caller's users/{user}/ prefix unless visibility=shared is given. Assumes authentication/authorization by gateway.
 // @Tags Files
 // @Accept multipart/form-data
 // @Param files formData file true "Files to upload" collectionFormat multi
+// @Param visibility formData string false "Set to 'shared' to upload under the shared/ prefix instead of the caller's own namespace"
 // @Success 201 {object} map[string]interface{} "message: Files uploaded successfully, uploaded_files: [filename1, filename2]"
 // @Failure 400 {object} map[string]string "detail: No files provided for upload."
 // @Failure 413 {object} map[string]string "detail: File exceeds the maximum allowed size."
@@ -195,6 +277,11 @@ func uploadFilesHandler(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusBadRequest, "No files provided for upload.")
 	}
 
+	prefix := userPrefix(userPreferredUsername)
+	if c.FormValue("visibility") == "shared" {
+		prefix = sharedPrefix
+	}
+
 	var uploadedFilenames []string
 	for _, fileHeader := range files {
 		if fileHeader.Filename == "" {
@@ -209,7 +296,7 @@ func uploadFilesHandler(c echo.Context) error {
 		}
 		defer file.Close()
 
-		uniqueFilename := fmt.Sprintf("%s_%s", uuid.New().String(), filepath.Base(fileHeader.Filename))
+		uniqueFilename := prefix + fmt.Sprintf("%s_%s", uuid.New().String(), filepath.Base(fileHeader.Filename))
 
 		pr, pw := io.Pipe()
 		go func() {
@@ -239,33 +326,39 @@ func uploadFilesHandler(c echo.Context) error {
 			}
 		}()
 
-		uploadInput := &s3manager.UploadInput{
-			Bucket: aws.String(s3Bucket),
-			Key:    aws.String(uniqueFilename),
-			Body:   pr,
-		}
-
-		_, err = s3Uploader.Upload(uploadInput)
+		info, err := storageBackend.Put(c.Request().Context(), PutObjectInput{
+			Key:         uniqueFilename,
+			Body:        pr,
+			ContentType: fileHeader.Header.Get("Content-Type"),
+			Metadata: map[string]string{
+				metaKeyOwner:            userPreferredUsername,
+				metaKeyOriginalFilename: fileHeader.Filename,
+			},
+		})
 		if err != nil {
 			if strings.Contains(err.Error(), fmt.Sprintf("exceeds the maximum allowed size of %dMB", maxFileSizeMB)) {
 				return echo.NewHTTPError(http.StatusRequestEntityTooLarge, err.Error())
 			}
-			log.Printf("Failed to upload file '%s' to S3 as '%s' for user %s: %v", fileHeader.Filename, uniqueFilename, userPreferredUsername, err)
+			log.Printf("Failed to upload file '%s' to storage as '%s' for user %s: %v", fileHeader.Filename, uniqueFilename, userPreferredUsername, err)
 			return echo.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("Could not upload file '%s'", fileHeader.Filename))
 		}
 		uploadedFilenames = append(uploadedFilenames, uniqueFilename)
 		log.Printf("File '%s' uploaded successfully as '%s' by user: %s", fileHeader.Filename, uniqueFilename, userPreferredUsername)
+
+		publishObjectEvent(eventObjectCreatedPut, uniqueFilename, info, userPreferredUsername)
+		maybeGenerateDerivativesAsync(uniqueFilename, userPreferredUsername)
 	}
 	return c.JSON(http.StatusCreated, map[string]interface{}{"message": "Files uploaded successfully", "uploaded_files": uploadedFilenames})
 }
 
-// getFileHandler retrieves a specific file from S3 and streams it to the client.
+// getFileHandler retrieves a specific file from storage and streams it to the client.
 // @Summary Get a file
-// @Description Retrieves a specific file by its filename. Assumes authentication/authorization by gateway.
+// @Description Retrieves a specific file by its key. Only the file's owner, an admin-role caller, or a principal granted ACL read access (for shared/ files) may retrieve it.
 // @Tags Files
 // @Produce octet-stream
-// @Param filename path string true "Name of the file to retrieve"
+// @Param filename path string true "Key of the file to retrieve"
 // @Success 200 {file} byte "File content"
+// @Failure 403 {object} map[string]string "detail: You do not have access to this file."
 // @Failure 404 {object} map[string]string "detail: File not found."
 // @Failure 500 {object} map[string]string "detail: Failed to retrieve file."
 // @Router /files/{filename} [get]
@@ -275,42 +368,57 @@ func getFileHandler(c echo.Context) error {
 		userPreferredUsername = "N/A (no X-User-Preferred-Username header)"
 	}
 
-	filename := c.Param("filename")
+	key := c.Param("*")
 
-	input := &s3.GetObjectInput{
-		Bucket: aws.String(s3Bucket),
-		Key:    aws.String(filename),
+	info, err := storageBackend.Stat(c.Request().Context(), key)
+	if err != nil {
+		if errors.Is(err, ErrNotExist) {
+			log.Printf("Attempted to access non-existent file: %s by user: %s", key, userPreferredUsername)
+			return echo.NewHTTPError(http.StatusNotFound, fmt.Sprintf("File '%s' not found.", key))
+		}
+		log.Printf("Error getting object '%s' from storage for user %s: %v", key, userPreferredUsername, err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to retrieve file.")
+	}
+	if err := checkReadAccess(info, userPreferredUsername, isAdminRequest(c)); err != nil {
+		log.Printf("User %s denied access to file: %s", userPreferredUsername, key)
+		return echo.NewHTTPError(http.StatusForbidden, "You do not have access to this file.")
 	}
 
-	result, err := s3Client.GetObject(input)
+	body, _, err := storageBackend.Get(c.Request().Context(), key)
 	if err != nil {
-		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == s3.ErrCodeNoSuchKey {
-			log.Printf("Attempted to access non-existent file: %s by user: %s", filename, userPreferredUsername)
-			return echo.NewHTTPError(http.StatusNotFound, fmt.Sprintf("File '%s' not found.", filename))
+		if errors.Is(err, ErrNotExist) {
+			return echo.NewHTTPError(http.StatusNotFound, fmt.Sprintf("File '%s' not found.", key))
 		}
-		log.Printf("Error getting object '%s' from S3 for user %s: %v", filename, userPreferredUsername, err)
+		log.Printf("Error getting object '%s' from storage for user %s: %v", key, userPreferredUsername, err)
 		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to retrieve file.")
 	}
-	defer result.Body.Close()
+	defer body.Close()
+
+	filename := info.Metadata[metaKeyOriginalFilename]
+	if filename == "" {
+		filename = filepath.Base(key)
+	}
 
-	c.Response().Header().Set("Content-Type", aws.StringValue(result.ContentType))
-	c.Response().Header().Set("Content-Length", fmt.Sprintf("%d", aws.Int64Value(result.ContentLength)))
+	c.Response().Header().Set("Content-Type", info.ContentType)
+	c.Response().Header().Set("Content-Length", fmt.Sprintf("%d", info.Size))
 	c.Response().Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", filename))
 
-	if _, err := io.Copy(c.Response().Writer, result.Body); err != nil {
-		log.Printf("Error streaming file '%s' from S3 to client for user %s: %v", filename, userPreferredUsername, err)
+	if _, err := io.Copy(c.Response().Writer, body); err != nil {
+		log.Printf("Error streaming file '%s' from storage to client for user %s: %v", key, userPreferredUsername, err)
 	}
 
-	log.Printf("Serving file: %s to user: %s", filename, userPreferredUsername)
+	log.Printf("Serving file: %s to user: %s", key, userPreferredUsername)
 	return nil
 }
 
-// deleteFileHandler deletes a specific file from S3.
+// deleteFileHandler deletes a specific file from storage.
 // @Summary Delete a file
-// @Description Deletes a specific file by its filename. Assumes authentication/authorization by gateway.
+// @Description Deletes a specific file by its key. Only the file's owner or an admin-role caller may delete it.
 // @Tags Files
-// @Param filename path string true "Name of the file to delete"
+// @Param filename path string true "Key of the file to delete"
 // @Success 204 "No Content"
+// @Failure 403 {object} map[string]string "detail: You do not have access to this file."
+// @Failure 404 {object} map[string]string "detail: File not found."
 // @Failure 500 {object} map[string]string "detail: Could not delete file."
 // @Router /files/{filename} [delete]
 func deleteFileHandler(c echo.Context) error {
@@ -319,19 +427,35 @@ func deleteFileHandler(c echo.Context) error {
 		userPreferredUsername = "N/A (no X-User-Preferred-Username header)"
 	}
 
-	filename := c.Param("filename")
+	key := c.Param("*")
+
+	info, err := storageBackend.Stat(c.Request().Context(), key)
+	if err != nil {
+		if errors.Is(err, ErrNotExist) {
+			return echo.NewHTTPError(http.StatusNotFound, fmt.Sprintf("File '%s' not found.", key))
+		}
+		log.Printf("Error statting object '%s' for user %s: %v", key, userPreferredUsername, err)
+		return echo.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("Could not delete file '%s'.", key))
+	}
+	if err := checkWriteAccess(info, userPreferredUsername, isAdminRequest(c)); err != nil {
+		log.Printf("User %s denied deletion of file: %s", userPreferredUsername, key)
+		return echo.NewHTTPError(http.StatusForbidden, "You do not have access to this file.")
+	}
 
-	input := &s3.DeleteObjectInput{
-		Bucket: aws.String(s3Bucket),
-		Key:    aws.String(filename),
+	if err := storageBackend.Delete(c.Request().Context(), key); err != nil {
+		log.Printf("Error deleting object '%s' for user %s: %v", key, userPreferredUsername, err)
+		return echo.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("Could not delete file '%s'.", key))
 	}
 
-	_, err := s3Client.DeleteObject(input)
-	if err != nil {
-		log.Printf("Error deleting S3 object '%s' for user %s: %v", filename, userPreferredUsername, err)
-		return echo.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("Could not delete file '%s'.", filename))
+	if isImageKey(key) {
+		for _, derivedKey := range []string{thumbnailKey(key), previewKey(key)} {
+			if err := storageBackend.Delete(c.Request().Context(), derivedKey); err != nil && !errors.Is(err, ErrNotExist) {
+				log.Printf("Error deleting derivative '%s' for user %s: %v", derivedKey, userPreferredUsername, err)
+			}
+		}
 	}
 
-	log.Printf("File '%s' deleted successfully by user: %s", filename, userPreferredUsername)
+	log.Printf("File '%s' deleted successfully by user: %s", key, userPreferredUsername)
+	publishObjectEvent(eventObjectRemovedDelete, key, info, userPreferredUsername)
 	return c.NoContent(http.StatusNoContent)
 }
@@ -0,0 +1,316 @@
+package main
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+// multipartSession tracks the object key and owner associated with an
+// in-progress multipart upload, keyed by the storage-issued upload ID.
+// Storage backends have no notion of "list my in-progress uploads by ID", so
+// we keep this small in-memory index to avoid requiring the client to resend
+// the key on every part request.
+type multipartSession struct {
+	Key      string
+	Filename string
+	Username string
+}
+
+var (
+	multipartSessions   = make(map[string]*multipartSession)
+	multipartSessionsMu sync.Mutex
+)
+
+// initiateMultipartUploadRequest is the JSON body for starting a multipart upload.
+type initiateMultipartUploadRequest struct {
+	Filename    string `json:"filename"`
+	ContentType string `json:"content_type"`
+}
+
+// initiateMultipartUploadHandler starts a resumable multipart upload session.
+// @Summary Initiate a multipart upload
+// @Description Starts a new multipart upload against the storage backend and returns an upload ID and object key to use for subsequent part uploads.
+// @Tags Multipart
+// @Accept json
+// @Produce json
+// @Param request body initiateMultipartUploadRequest true "Filename and content type of the upload"
+// @Success 201 {object} map[string]string "upload_id, key"
+// @Failure 400 {object} map[string]string "detail: filename is required."
+// @Failure 500 {object} map[string]string "detail: Could not initiate multipart upload."
+// @Router /files/multipart [post]
+func initiateMultipartUploadHandler(c echo.Context) error {
+	userPreferredUsername := c.Request().Header.Get("X-User-Preferred-Username")
+	if userPreferredUsername == "" {
+		userPreferredUsername = "N/A (no X-User-Preferred-Username header)"
+	}
+
+	var req initiateMultipartUploadRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Invalid request body: %v", err))
+	}
+	if req.Filename == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "filename is required.")
+	}
+
+	uniqueFilename := userPrefix(userPreferredUsername) + fmt.Sprintf("%s_%s", uuid.New().String(), filepath.Base(req.Filename))
+
+	metadata := map[string]string{
+		metaKeyOwner:            userPreferredUsername,
+		metaKeyOriginalFilename: req.Filename,
+	}
+	uploadID, err := storageBackend.InitMultipart(c.Request().Context(), uniqueFilename, req.ContentType, metadata)
+	if err != nil {
+		log.Printf("Failed to initiate multipart upload for '%s' by user %s: %v", req.Filename, userPreferredUsername, err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Could not initiate multipart upload.")
+	}
+
+	multipartSessionsMu.Lock()
+	multipartSessions[uploadID] = &multipartSession{
+		Key:      uniqueFilename,
+		Filename: req.Filename,
+		Username: userPreferredUsername,
+	}
+	multipartSessionsMu.Unlock()
+
+	log.Printf("Multipart upload initiated for '%s' as '%s' (upload_id=%s) by user: %s", req.Filename, uniqueFilename, uploadID, userPreferredUsername)
+	return c.JSON(http.StatusCreated, map[string]string{
+		"upload_id": uploadID,
+		"key":       uniqueFilename,
+	})
+}
+
+// uploadPartHandler uploads a single part of a multipart upload.
+// @Summary Upload a part
+// @Description Uploads one part of an in-progress multipart upload. The Content-MD5 header, if present, is verified against the received bytes before the part is forwarded to storage.
+// @Tags Multipart
+// @Accept octet-stream
+// @Produce json
+// @Param id path string true "Upload ID"
+// @Param partNum path int true "Part number (1-10000)"
+// @Success 200 {object} map[string]interface{} "part_number, etag"
+// @Failure 400 {object} map[string]string "detail: Invalid part number."
+// @Failure 403 {object} map[string]string "detail: Only the owner may upload parts to this upload."
+// @Failure 404 {object} map[string]string "detail: Unknown upload ID."
+// @Failure 422 {object} map[string]string "detail: Content-MD5 does not match the received bytes."
+// @Failure 500 {object} map[string]string "detail: Could not upload part."
+// @Router /files/multipart/{id}/{partNum} [put]
+func uploadPartHandler(c echo.Context) error {
+	userPreferredUsername := c.Request().Header.Get("X-User-Preferred-Username")
+	if userPreferredUsername == "" {
+		userPreferredUsername = "N/A (no X-User-Preferred-Username header)"
+	}
+
+	uploadID := c.Param("id")
+	partNum, err := strconv.ParseInt(c.Param("partNum"), 10, 64)
+	if err != nil || partNum < 1 || partNum > 10000 {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid part number.")
+	}
+
+	session := lookupMultipartSession(uploadID)
+	if session == nil {
+		return echo.NewHTTPError(http.StatusNotFound, "Unknown upload ID.")
+	}
+	if err := checkMultipartOwnership(session, userPreferredUsername, isAdminRequest(c)); err != nil {
+		return echo.NewHTTPError(http.StatusForbidden, "Only the owner may upload parts to this upload.")
+	}
+
+	body, err := io.ReadAll(c.Request().Body)
+	if err != nil {
+		log.Printf("Error reading part %d body for upload %s: %v", partNum, uploadID, err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Could not read part body.")
+	}
+
+	if expectedMD5 := c.Request().Header.Get("Content-MD5"); expectedMD5 != "" {
+		sum := md5.Sum(body)
+		if base64.StdEncoding.EncodeToString(sum[:]) != expectedMD5 {
+			return echo.NewHTTPError(http.StatusUnprocessableEntity, "Content-MD5 does not match the received bytes.")
+		}
+	}
+
+	etag, err := storageBackend.UploadPart(c.Request().Context(), session.Key, uploadID, partNum, bytes.NewReader(body))
+	if err != nil {
+		log.Printf("Failed to upload part %d for upload %s by user %s: %v", partNum, uploadID, session.Username, err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Could not upload part.")
+	}
+
+	log.Printf("Part %d of upload %s uploaded successfully by user: %s", partNum, uploadID, session.Username)
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"part_number": partNum,
+		"etag":        etag,
+	})
+}
+
+// listPartsHandler lists the parts already uploaded for a multipart session, so a client can resume after a crash.
+// @Summary List uploaded parts
+// @Description Lists the parts already received for an in-progress multipart upload.
+// @Tags Multipart
+// @Produce json
+// @Param id path string true "Upload ID"
+// @Success 200 {array} map[string]interface{} "part_number, etag, size"
+// @Failure 403 {object} map[string]string "detail: Only the owner may list parts of this upload."
+// @Failure 404 {object} map[string]string "detail: Unknown upload ID."
+// @Failure 500 {object} map[string]string "detail: Could not list parts."
+// @Router /files/multipart/{id} [get]
+func listPartsHandler(c echo.Context) error {
+	userPreferredUsername := c.Request().Header.Get("X-User-Preferred-Username")
+	if userPreferredUsername == "" {
+		userPreferredUsername = "N/A (no X-User-Preferred-Username header)"
+	}
+
+	uploadID := c.Param("id")
+	session := lookupMultipartSession(uploadID)
+	if session == nil {
+		return echo.NewHTTPError(http.StatusNotFound, "Unknown upload ID.")
+	}
+	if err := checkMultipartOwnership(session, userPreferredUsername, isAdminRequest(c)); err != nil {
+		return echo.NewHTTPError(http.StatusForbidden, "Only the owner may list parts of this upload.")
+	}
+
+	parts, err := storageBackend.ListParts(c.Request().Context(), session.Key, uploadID)
+	if err != nil {
+		log.Printf("Failed to list parts for upload %s by user %s: %v", uploadID, session.Username, err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Could not list parts.")
+	}
+
+	result := make([]map[string]interface{}, len(parts))
+	for i, p := range parts {
+		result[i] = map[string]interface{}{
+			"part_number": p.PartNumber,
+			"etag":        p.ETag,
+			"size":        p.Size,
+		}
+	}
+	return c.JSON(http.StatusOK, result)
+}
+
+// completedPart identifies one part in a completeMultipartUploadRequest.
+type completedPart struct {
+	PartNumber int64  `json:"part_number"`
+	ETag       string `json:"etag"`
+}
+
+// completeMultipartUploadRequest is the JSON body for finalizing a multipart upload.
+type completeMultipartUploadRequest struct {
+	Parts []completedPart `json:"parts"`
+}
+
+// completeMultipartUploadHandler finalizes a multipart upload from the ordered list of part ETags.
+// @Summary Complete a multipart upload
+// @Description Finalizes a multipart upload given the ordered {part_number, etag} list returned while uploading parts.
+// @Tags Multipart
+// @Accept json
+// @Produce json
+// @Param id path string true "Upload ID"
+// @Param request body completeMultipartUploadRequest true "Ordered list of uploaded parts"
+// @Success 201 {object} map[string]string "message, key"
+// @Failure 400 {object} map[string]string "detail: parts must not be empty."
+// @Failure 403 {object} map[string]string "detail: Only the owner may complete this upload."
+// @Failure 404 {object} map[string]string "detail: Unknown upload ID."
+// @Failure 500 {object} map[string]string "detail: Could not complete multipart upload."
+// @Router /files/multipart/{id}/complete [post]
+func completeMultipartUploadHandler(c echo.Context) error {
+	userPreferredUsername := c.Request().Header.Get("X-User-Preferred-Username")
+	if userPreferredUsername == "" {
+		userPreferredUsername = "N/A (no X-User-Preferred-Username header)"
+	}
+
+	uploadID := c.Param("id")
+	session := lookupMultipartSession(uploadID)
+	if session == nil {
+		return echo.NewHTTPError(http.StatusNotFound, "Unknown upload ID.")
+	}
+	if err := checkMultipartOwnership(session, userPreferredUsername, isAdminRequest(c)); err != nil {
+		return echo.NewHTTPError(http.StatusForbidden, "Only the owner may complete this upload.")
+	}
+
+	var req completeMultipartUploadRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Invalid request body: %v", err))
+	}
+	if len(req.Parts) == 0 {
+		return echo.NewHTTPError(http.StatusBadRequest, "parts must not be empty.")
+	}
+
+	sort.Slice(req.Parts, func(i, j int) bool { return req.Parts[i].PartNumber < req.Parts[j].PartNumber })
+
+	parts := make([]CompletedPart, len(req.Parts))
+	for i, p := range req.Parts {
+		parts[i] = CompletedPart{PartNumber: p.PartNumber, ETag: p.ETag}
+	}
+
+	if err := storageBackend.CompleteMultipart(c.Request().Context(), session.Key, uploadID, parts); err != nil {
+		log.Printf("Failed to complete multipart upload %s by user %s: %v", uploadID, session.Username, err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Could not complete multipart upload.")
+	}
+
+	multipartSessionsMu.Lock()
+	delete(multipartSessions, uploadID)
+	multipartSessionsMu.Unlock()
+
+	log.Printf("Multipart upload %s completed as '%s' by user: %s", uploadID, session.Key, session.Username)
+	if info, statErr := storageBackend.Stat(c.Request().Context(), session.Key); statErr == nil {
+		publishObjectEvent(eventObjectCreatedPut, session.Key, info, session.Username)
+	} else {
+		log.Printf("Could not stat '%s' after completing multipart upload %s: %v", session.Key, uploadID, statErr)
+	}
+	return c.JSON(http.StatusCreated, map[string]string{
+		"message": "Multipart upload completed successfully",
+		"key":     session.Key,
+	})
+}
+
+// abortMultipartUploadHandler aborts an in-progress multipart upload and releases any uploaded parts.
+// @Summary Abort a multipart upload
+// @Description Aborts an in-progress multipart upload, discarding any parts already uploaded.
+// @Tags Multipart
+// @Param id path string true "Upload ID"
+// @Success 204 "No Content"
+// @Failure 403 {object} map[string]string "detail: Only the owner may abort this upload."
+// @Failure 404 {object} map[string]string "detail: Unknown upload ID."
+// @Failure 500 {object} map[string]string "detail: Could not abort multipart upload."
+// @Router /files/multipart/{id} [delete]
+func abortMultipartUploadHandler(c echo.Context) error {
+	userPreferredUsername := c.Request().Header.Get("X-User-Preferred-Username")
+	if userPreferredUsername == "" {
+		userPreferredUsername = "N/A (no X-User-Preferred-Username header)"
+	}
+
+	uploadID := c.Param("id")
+	session := lookupMultipartSession(uploadID)
+	if session == nil {
+		return echo.NewHTTPError(http.StatusNotFound, "Unknown upload ID.")
+	}
+	if err := checkMultipartOwnership(session, userPreferredUsername, isAdminRequest(c)); err != nil {
+		return echo.NewHTTPError(http.StatusForbidden, "Only the owner may abort this upload.")
+	}
+
+	if err := storageBackend.AbortMultipart(c.Request().Context(), session.Key, uploadID); err != nil {
+		log.Printf("Failed to abort multipart upload %s by user %s: %v", uploadID, session.Username, err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Could not abort multipart upload.")
+	}
+
+	multipartSessionsMu.Lock()
+	delete(multipartSessions, uploadID)
+	multipartSessionsMu.Unlock()
+
+	log.Printf("Multipart upload %s aborted by user: %s", uploadID, session.Username)
+	return c.NoContent(http.StatusNoContent)
+}
+
+func lookupMultipartSession(uploadID string) *multipartSession {
+	multipartSessionsMu.Lock()
+	defer multipartSessionsMu.Unlock()
+	return multipartSessions[uploadID]
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"log"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	eventObjectCreatedPut    = "s3:ObjectCreated:Put"
+	eventObjectRemovedDelete = "s3:ObjectRemoved:Delete"
+)
+
+// ObjectEvent describes a single object lifecycle event, modeled after the
+// S3 event notification JSON schema so existing S3-event consumers can be
+// pointed at this service's webhook/NATS/Kafka sinks with minimal changes.
+type ObjectEvent struct {
+	Event       string    `json:"event"`
+	Bucket      string    `json:"bucket"`
+	Key         string    `json:"key"`
+	Size        int64     `json:"size"`
+	ContentType string    `json:"contentType,omitempty"`
+	ETag        string    `json:"etag,omitempty"`
+	User        string    `json:"user"`
+	Timestamp   time.Time `json:"timestamp"`
+}
+
+// Publisher dispatches object events to a notification sink. Publish must
+// not block the request path; implementations that talk to a remote system
+// are expected to queue events and deliver them from their own goroutines.
+type Publisher interface {
+	Publish(event ObjectEvent)
+}
+
+// noopPublisher is the default Publisher when no sink is configured.
+type noopPublisher struct{}
+
+func (noopPublisher) Publish(ObjectEvent) {}
+
+var notificationPublisher Publisher = noopPublisher{}
+
+// initNotificationPublisher selects and initializes the Publisher backing
+// object-event notifications, based on NOTIFICATIONS_DRIVER.
+func initNotificationPublisher() {
+	switch strings.ToLower(os.Getenv("NOTIFICATIONS_DRIVER")) {
+	case "", "none":
+		notificationPublisher = noopPublisher{}
+	case "webhook":
+		url := os.Getenv("NOTIFICATIONS_WEBHOOK_URL")
+		if url == "" {
+			log.Fatal("NOTIFICATIONS_WEBHOOK_URL must be set when NOTIFICATIONS_DRIVER=webhook.")
+		}
+		notificationPublisher = newWebhookPublisher(url, os.Getenv("NOTIFICATIONS_WEBHOOK_SECRET"))
+		log.Printf("Webhook notification publisher initialized for %s", url)
+	case "nats":
+		publisher, err := newNATSPublisher(os.Getenv("NOTIFICATIONS_NATS_URL"), os.Getenv("NOTIFICATIONS_NATS_SUBJECT"))
+		if err != nil {
+			log.Fatalf("Failed to initialize NATS notification publisher: %v", err)
+		}
+		notificationPublisher = publisher
+		log.Printf("NATS notification publisher initialized for subject %s", publisher.subject)
+	case "kafka":
+		notificationPublisher = newKafkaPublisher(strings.Split(os.Getenv("NOTIFICATIONS_KAFKA_BROKERS"), ","), os.Getenv("NOTIFICATIONS_KAFKA_TOPIC"))
+		log.Printf("Kafka notification publisher initialized for topic %s", os.Getenv("NOTIFICATIONS_KAFKA_TOPIC"))
+	default:
+		log.Fatalf("Unknown NOTIFICATIONS_DRIVER %q, expected \"webhook\", \"nats\", \"kafka\" or \"none\".", os.Getenv("NOTIFICATIONS_DRIVER"))
+	}
+}
+
+// publishObjectEvent builds and dispatches an ObjectEvent for key, logging
+// (with the same user-attribution pattern the handlers use) rather than
+// failing the request if the configured sink can't be reached.
+func publishObjectEvent(eventName, key string, info *ObjectInfo, user string) {
+	event := ObjectEvent{
+		Event:     eventName,
+		Bucket:    s3Bucket,
+		Key:       key,
+		User:      user,
+		Timestamp: time.Now().UTC(),
+	}
+	if info != nil {
+		event.Size = info.Size
+		event.ContentType = info.ContentType
+		event.ETag = info.ETag
+	}
+	notificationPublisher.Publish(event)
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+
+	kafka "github.com/segmentio/kafka-go"
+)
+
+const (
+	kafkaQueueCapacity = 256
+	kafkaWorkerLimit   = 4
+)
+
+// kafkaPublisher publishes object events to a Kafka topic. Publish enqueues
+// onto a bounded in-memory queue drained by a small worker pool, mirroring
+// webhookPublisher, so a slow or unreachable broker can't block the request
+// path.
+type kafkaPublisher struct {
+	writer *kafka.Writer
+	queue  chan ObjectEvent
+}
+
+func newKafkaPublisher(brokers []string, topic string) *kafkaPublisher {
+	p := &kafkaPublisher{
+		writer: &kafka.Writer{
+			Addr:     kafka.TCP(brokers...),
+			Topic:    topic,
+			Balancer: &kafka.LeastBytes{},
+		},
+		queue: make(chan ObjectEvent, kafkaQueueCapacity),
+	}
+	for i := 0; i < kafkaWorkerLimit; i++ {
+		go p.worker()
+	}
+	return p
+}
+
+func (p *kafkaPublisher) Publish(event ObjectEvent) {
+	select {
+	case p.queue <- event:
+	default:
+		log.Printf("Kafka notification queue full, dropping event %s for key %s", event.Event, event.Key)
+	}
+}
+
+func (p *kafkaPublisher) worker() {
+	for event := range p.queue {
+		if err := p.deliver(event); err != nil {
+			log.Printf("Failed to publish Kafka notification for '%s' (%s), uploaded by %s: %v", event.Key, event.Event, event.User, err)
+		}
+	}
+}
+
+func (p *kafkaPublisher) deliver(event ObjectEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	return p.writer.WriteMessages(context.Background(), kafka.Message{
+		Key:   []byte(event.Key),
+		Value: body,
+	})
+}
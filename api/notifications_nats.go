@@ -0,0 +1,42 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+
+	"github.com/nats-io/nats.go"
+)
+
+// natsPublisher publishes object events to a NATS subject. Publish hands
+// the event to NATS's own async client, which buffers and flushes on its
+// own goroutine, so delivery never blocks the request path.
+type natsPublisher struct {
+	conn    *nats.Conn
+	subject string
+}
+
+func newNATSPublisher(url, subject string) (*natsPublisher, error) {
+	if url == "" {
+		url = nats.DefaultURL
+	}
+	if subject == "" {
+		subject = "filestorage.object-events"
+	}
+
+	conn, err := nats.Connect(url)
+	if err != nil {
+		return nil, err
+	}
+	return &natsPublisher{conn: conn, subject: subject}, nil
+}
+
+func (p *natsPublisher) Publish(event ObjectEvent) {
+	body, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("Failed to marshal notification for '%s' (%s): %v", event.Key, event.Event, err)
+		return
+	}
+	if err := p.conn.Publish(p.subject, body); err != nil {
+		log.Printf("Failed to publish NATS notification for '%s' (%s), uploaded by %s: %v", event.Key, event.Event, event.User, err)
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+)
+
+const (
+	webhookQueueCapacity  = 256
+	webhookWorkerLimit    = 4
+	webhookMaxAttempts    = 5
+	webhookInitialBackoff = 500 * time.Millisecond
+)
+
+// webhookPublisher delivers object events to an HTTP endpoint, signing each
+// payload with HMAC-SHA256 so the receiver can verify it came from this
+// service. Publish enqueues onto a bounded in-memory queue drained by a
+// small worker pool; if the queue is full the event is dropped and logged
+// rather than blocking the caller.
+type webhookPublisher struct {
+	url    string
+	secret string
+	client *http.Client
+	queue  chan ObjectEvent
+}
+
+func newWebhookPublisher(url, secret string) *webhookPublisher {
+	p := &webhookPublisher{
+		url:    url,
+		secret: secret,
+		client: &http.Client{Timeout: 10 * time.Second},
+		queue:  make(chan ObjectEvent, webhookQueueCapacity),
+	}
+	for i := 0; i < webhookWorkerLimit; i++ {
+		go p.worker()
+	}
+	return p
+}
+
+func (p *webhookPublisher) Publish(event ObjectEvent) {
+	select {
+	case p.queue <- event:
+	default:
+		log.Printf("Webhook notification queue full, dropping event %s for key %s", event.Event, event.Key)
+	}
+}
+
+func (p *webhookPublisher) worker() {
+	for event := range p.queue {
+		if err := p.deliver(event); err != nil {
+			log.Printf("Failed to deliver webhook notification for '%s' (%s), uploaded by %s: %v", event.Key, event.Event, event.User, err)
+		}
+	}
+}
+
+// deliver POSTs event to the configured URL, retrying with exponential
+// backoff on transport errors or non-2xx responses.
+func (p *webhookPublisher) deliver(event ObjectEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	backoff := webhookInitialBackoff
+	var lastErr error
+	for attempt := 0; attempt < webhookMaxAttempts; attempt++ {
+		if attempt > 0 {
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+
+		req, err := http.NewRequest(http.MethodPost, p.url, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		if p.secret != "" {
+			req.Header.Set("X-Webhook-Signature", signWebhookPayload(p.secret, body))
+		}
+
+		resp, err := p.client.Do(req)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		resp.Body.Close()
+		if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+			return nil
+		}
+		lastErr = fmt.Errorf("webhook endpoint returned status %d", resp.StatusCode)
+	}
+	return lastErr
+}
+
+func signWebhookPayload(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	sharedPrefix = "shared/" // objects uploaded here are listable by anyone the owner grants ACL access to
+
+	metaKeyOwner            = "owner"
+	metaKeyOriginalFilename = "original-filename"
+	metaKeyACL              = "acl" // comma-separated list of principals granted read access
+)
+
+// adminRoleHeader/adminRoleValue let an operator designate a header/value
+// pair (e.g. set by an upstream auth gateway) that bypasses per-user
+// namespacing, mirroring how the other handlers trust gateway-supplied
+// headers rather than authenticating requests themselves.
+var (
+	adminRoleHeader = os.Getenv("ADMIN_ROLE_HEADER")
+	adminRoleValue  = os.Getenv("ADMIN_ROLE_VALUE")
+)
+
+var errForbidden = errors.New("forbidden")
+
+func isAdminRequest(c echo.Context) bool {
+	if adminRoleHeader == "" || adminRoleValue == "" {
+		return false
+	}
+	for _, role := range strings.Split(c.Request().Header.Get(adminRoleHeader), ",") {
+		if strings.TrimSpace(role) == adminRoleValue {
+			return true
+		}
+	}
+	return false
+}
+
+// sanitizePrincipal strips path separators from a username before it's used
+// as a key prefix, and neutralizes "." / ".." segments so a caller can't
+// send X-User-Preferred-Username: .. and have userPrefix's "users/../"
+// collapsed by a backend's path cleaning (e.g. storage_local.go's
+// objectPath) into a location outside the users/ namespace entirely.
+func sanitizePrincipal(name string) string {
+	sanitized := strings.NewReplacer("/", "_", "\\", "_").Replace(name)
+	if sanitized == "" || strings.Trim(sanitized, ".") == "" {
+		return "_" + sanitized
+	}
+	return sanitized
+}
+
+// userPrefix returns the object-key prefix a given user's uploads are namespaced under.
+func userPrefix(user string) string {
+	return fmt.Sprintf("users/%s/", sanitizePrincipal(user))
+}
+
+// aclPrincipals parses the comma-separated "acl" metadata field on an object.
+func aclPrincipals(info *ObjectInfo) []string {
+	raw := info.Metadata[metaKeyACL]
+	if raw == "" {
+		return nil
+	}
+	parts := strings.Split(raw, ",")
+	principals := make([]string, 0, len(parts))
+	for _, p := range parts {
+		if p = strings.TrimSpace(p); p != "" {
+			principals = append(principals, p)
+		}
+	}
+	return principals
+}
+
+func aclContains(info *ObjectInfo, user string) bool {
+	for _, p := range aclPrincipals(info) {
+		if p == user {
+			return true
+		}
+	}
+	return false
+}
+
+// checkReadAccess returns nil if user may read the object described by info: they own it,
+// the request carries the admin role, or the object is shared and its ACL names them.
+func checkReadAccess(info *ObjectInfo, user string, admin bool) error {
+	if admin || info.Metadata[metaKeyOwner] == user {
+		return nil
+	}
+	if strings.HasPrefix(info.Key, sharedPrefix) && aclContains(info, user) {
+		return nil
+	}
+	return errForbidden
+}
+
+// checkWriteAccess returns nil if user may modify/delete the object described by info: only the owner or an admin may.
+func checkWriteAccess(info *ObjectInfo, user string, admin bool) error {
+	if admin || info.Metadata[metaKeyOwner] == user {
+		return nil
+	}
+	return errForbidden
+}
+
+// checkMultipartOwnership returns nil if user may act on an in-progress
+// multipart session: only the session's creator or an admin may.
+func checkMultipartOwnership(session *multipartSession, user string, admin bool) error {
+	if admin || session.Username == user {
+		return nil
+	}
+	return errForbidden
+}
+
+// shareRequest is the JSON body for granting other principals read access to a shared object.
+type shareRequest struct {
+	Principals []string `json:"principals"`
+}
+
+// shareFileHandler grants one or more other principals read access to an object the caller owns under shared/.
+// @Summary Share a file with other principals
+// @Description Grants the named principals read access to a shared/ object by updating its ACL metadata sidecar. Only the object's owner (or an admin) may do this.
+// @Tags Files
+// @Accept json
+// @Param filename path string true "Key of the shared file (must be under shared/)"
+// @Param request body shareRequest true "Principals to grant read access to"
+// @Success 204 "No Content"
+// @Failure 400 {object} map[string]string "detail: Only objects under shared/ support ACLs."
+// @Failure 403 {object} map[string]string "detail: Only the owner may share this file."
+// @Failure 404 {object} map[string]string "detail: File not found."
+// @Failure 500 {object} map[string]string "detail: Could not update sharing policy."
+// @Router /files/share/{filename} [post]
+func shareFileHandler(c echo.Context) error {
+	userPreferredUsername := c.Request().Header.Get("X-User-Preferred-Username")
+	if userPreferredUsername == "" {
+		userPreferredUsername = "N/A (no X-User-Preferred-Username header)"
+	}
+
+	key := c.Param("*")
+	if !strings.HasPrefix(key, sharedPrefix) {
+		return echo.NewHTTPError(http.StatusBadRequest, "Only objects under shared/ support ACLs.")
+	}
+
+	var req shareRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Invalid request body: %v", err))
+	}
+
+	info, err := storageBackend.Stat(c.Request().Context(), key)
+	if err != nil {
+		if errors.Is(err, ErrNotExist) {
+			return echo.NewHTTPError(http.StatusNotFound, fmt.Sprintf("File '%s' not found.", key))
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Could not update sharing policy.")
+	}
+	if err := checkWriteAccess(info, userPreferredUsername, isAdminRequest(c)); err != nil {
+		return echo.NewHTTPError(http.StatusForbidden, "Only the owner may share this file.")
+	}
+
+	principals := aclPrincipals(info)
+	for _, p := range req.Principals {
+		p = strings.TrimSpace(p)
+		if p == "" || aclContainsString(principals, p) {
+			continue
+		}
+		principals = append(principals, p)
+	}
+
+	metadata := info.Metadata
+	if metadata == nil {
+		metadata = map[string]string{}
+	}
+	metadata[metaKeyACL] = strings.Join(principals, ",")
+
+	if err := storageBackend.SetMetadata(c.Request().Context(), key, metadata); err != nil {
+		log.Printf("Failed to update ACL for '%s' by user %s: %v", key, userPreferredUsername, err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Could not update sharing policy.")
+	}
+
+	log.Printf("File '%s' shared with %v by user: %s", key, req.Principals, userPreferredUsername)
+	return c.NoContent(http.StatusNoContent)
+}
+
+func aclContainsString(principals []string, p string) bool {
+	for _, existing := range principals {
+		if existing == p {
+			return true
+		}
+	}
+	return false
+}
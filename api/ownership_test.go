@@ -0,0 +1,75 @@
+package main
+
+import "testing"
+
+func TestSanitizePrincipal(t *testing.T) {
+	cases := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"plain username", "alice", "alice"},
+		{"slash separators replaced", "a/b\\c", "a_b_c"},
+		{"dot rejected", ".", "_."},
+		{"dot-dot rejected", "..", "_.."},
+		{"empty rejected", "", "_"},
+		{"all-dots rejected", "...", "_..."},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := sanitizePrincipal(tc.in); got != tc.want {
+				t.Errorf("sanitizePrincipal(%q) = %q, want %q", tc.in, got, tc.want)
+			}
+		})
+	}
+}
+
+// TestUserPrefix_RejectsTraversal guards against a caller sending
+// X-User-Preferred-Username: .. and having userPrefix's "users/../" cleaned
+// by a backend (e.g. storage_local.go's objectPath) into a path outside the
+// users/ namespace.
+func TestUserPrefix_RejectsTraversal(t *testing.T) {
+	for _, name := range []string{".", "..", "../../etc"} {
+		prefix := userPrefix(name)
+		if prefix == "users/../" || prefix == "users/../../" {
+			t.Errorf("userPrefix(%q) = %q, still traversable", name, prefix)
+		}
+	}
+}
+
+func TestCheckMultipartOwnership(t *testing.T) {
+	session := &multipartSession{Key: "users/alice/foo", Username: "alice"}
+
+	cases := []struct {
+		name    string
+		user    string
+		admin   bool
+		wantErr bool
+	}{
+		{"owner may act", "alice", false, false},
+		{"admin may act regardless of owner", "bob", true, false},
+		{"non-owner rejected", "bob", false, true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := checkMultipartOwnership(session, tc.user, tc.admin)
+			if (err != nil) != tc.wantErr {
+				t.Errorf("checkMultipartOwnership(%q, admin=%v) error = %v, wantErr %v", tc.user, tc.admin, err, tc.wantErr)
+			}
+		})
+	}
+}
+
+// TestCheckMultipartOwnership_MissingHeaderFallback checks that a session
+// stored under the sentinel username that handlers substitute for a missing
+// X-User-Preferred-Username header is accepted for a later caller carrying
+// that same sentinel. It calls checkMultipartOwnership directly, so it does
+// not exercise the handlers' own header fallback.
+func TestCheckMultipartOwnership_MissingHeaderFallback(t *testing.T) {
+	const sentinel = "N/A (no X-User-Preferred-Username header)"
+	session := &multipartSession{Key: "users/_/foo", Username: sentinel}
+
+	if err := checkMultipartOwnership(session, sentinel, false); err != nil {
+		t.Fatalf("creator of a header-less session was rejected on a subsequent header-less call: %v", err)
+	}
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	defaultPresignExpirySeconds = 900 // 15 minutes
+
+	// defaultMaxPresignUploadBytes is the default ceiling on the content_length
+	// a client may declare for a presigned upload. A request that omits
+	// content_length is not capped by it.
+	defaultMaxPresignUploadBytes int64 = 5 * 1024 * 1024 * 1024 // 5 GB
+)
+
+// maxPresignExpirySeconds caps how long a presigned URL issued by this
+// service may remain valid, configurable via PRESIGN_MAX_EXPIRES_SECONDS.
+var maxPresignExpirySeconds = parsePresignMaxExpiry(os.Getenv("PRESIGN_MAX_EXPIRES_SECONDS"))
+
+// maxPresignUploadBytes caps the content_length a client may declare for a
+// presigned upload, configurable via PRESIGN_MAX_UPLOAD_BYTES. It does not
+// apply when the client omits content_length.
+var maxPresignUploadBytes = parsePresignMaxUploadBytes(os.Getenv("PRESIGN_MAX_UPLOAD_BYTES"))
+
+func parsePresignMaxExpiry(raw string) int {
+	if raw == "" {
+		return 3600 // 1 hour
+	}
+	parsed, err := strconv.Atoi(raw)
+	if err != nil || parsed <= 0 {
+		log.Fatalf("Invalid PRESIGN_MAX_EXPIRES_SECONDS value %q", raw)
+	}
+	return parsed
+}
+
+func parsePresignMaxUploadBytes(raw string) int64 {
+	if raw == "" {
+		return defaultMaxPresignUploadBytes
+	}
+	parsed, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil || parsed <= 0 {
+		log.Fatalf("Invalid PRESIGN_MAX_UPLOAD_BYTES value %q", raw)
+	}
+	return parsed
+}
+
+// presignUploadRequest is the JSON body for requesting a presigned upload URL.
+type presignUploadRequest struct {
+	Filename      string `json:"filename"`
+	ContentType   string `json:"content_type"`
+	ContentLength int64  `json:"content_length"`
+	ExpiresIn     int    `json:"expires_in"`
+}
+
+// presignUploadHandler issues a presigned URL the client can PUT a file's bytes to directly against the storage backend.
+// @Summary Get a presigned upload URL
+// @Description Returns a time-limited URL for uploading a file directly to S3/MinIO, bypassing this service for the data transfer. Callers that want the upload size enforced must declare content_length, up to the server's configured maximum; an omitted content_length leaves the PUT itself unbounded, since S3 signs Content-Length into the presigned request and a placeholder value would force every upload to be exactly that size.
+// @Tags Presign
+// @Accept json
+// @Produce json
+// @Param request body presignUploadRequest true "Filename, content type and optional size/expiry of the upload"
+// @Success 201 {object} map[string]interface{} "url, method, headers, key, expires_at"
+// @Failure 400 {object} map[string]string "detail: filename is required."
+// @Failure 400 {object} map[string]string "detail: content_length exceeds the maximum allowed upload size."
+// @Failure 501 {object} map[string]string "detail: Presigned URLs are not supported by the active storage driver."
+// @Failure 500 {object} map[string]string "detail: Could not create presigned upload URL."
+// @Router /files/presign/upload [post]
+func presignUploadHandler(c echo.Context) error {
+	userPreferredUsername := c.Request().Header.Get("X-User-Preferred-Username")
+	if userPreferredUsername == "" {
+		userPreferredUsername = "N/A (no X-User-Preferred-Username header)"
+	}
+
+	s3Backend, ok := storageBackend.(*s3Storage)
+	if !ok {
+		return echo.NewHTTPError(http.StatusNotImplemented, "Presigned URLs are not supported by the active storage driver.")
+	}
+
+	var req presignUploadRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Invalid request body: %v", err))
+	}
+	if req.Filename == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "filename is required.")
+	}
+	if req.ContentLength > maxPresignUploadBytes {
+		return echo.NewHTTPError(http.StatusBadRequest, "content_length exceeds the maximum allowed upload size.")
+	}
+
+	ttl := clampPresignExpiry(req.ExpiresIn)
+	uniqueFilename := userPrefix(userPreferredUsername) + fmt.Sprintf("%s_%s", uuid.New().String(), filepath.Base(req.Filename))
+
+	metadata := map[string]string{
+		metaKeyOwner:            userPreferredUsername,
+		metaKeyOriginalFilename: req.Filename,
+	}
+	// req.ContentLength is passed through as-is: PresignPut only sets the
+	// signed Content-Length header when it's > 0, so an omitted length
+	// leaves the upload unbounded at the HTTP layer rather than forcing an
+	// exact-size PUT against a placeholder cap.
+	url, headers, err := s3Backend.PresignPut(c.Request().Context(), uniqueFilename, req.ContentType, req.ContentLength, metadata, ttl)
+	if err != nil {
+		log.Printf("Failed to create presigned upload URL for '%s' by user %s: %v", req.Filename, userPreferredUsername, err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Could not create presigned upload URL.")
+	}
+
+	log.Printf("Presigned upload URL issued for '%s' as '%s' (expires in %v) by user: %s", req.Filename, uniqueFilename, ttl, userPreferredUsername)
+	return c.JSON(http.StatusCreated, map[string]interface{}{
+		"url":        url,
+		"method":     http.MethodPut,
+		"headers":    headers,
+		"key":        uniqueFilename,
+		"expires_at": time.Now().Add(ttl).UTC().Format(time.RFC3339),
+	})
+}
+
+// presignDownloadHandler issues a presigned URL the client can GET a file's bytes from directly against the storage backend.
+// @Summary Get a presigned download URL
+// @Description Returns a time-limited URL for downloading a file directly from S3/MinIO, bypassing this service for the data transfer.
+// @Tags Presign
+// @Produce json
+// @Param filename path string true "Key of the file to download"
+// @Param expires_in query int false "Requested validity in seconds, capped by the server's configured maximum"
+// @Success 200 {object} map[string]interface{} "url, method, headers, expires_at"
+// @Failure 403 {object} map[string]string "detail: You do not have access to this file."
+// @Failure 404 {object} map[string]string "detail: File not found."
+// @Failure 501 {object} map[string]string "detail: Presigned URLs are not supported by the active storage driver."
+// @Failure 500 {object} map[string]string "detail: Could not create presigned download URL."
+// @Router /files/presign/{filename} [get]
+func presignDownloadHandler(c echo.Context) error {
+	userPreferredUsername := c.Request().Header.Get("X-User-Preferred-Username")
+	if userPreferredUsername == "" {
+		userPreferredUsername = "N/A (no X-User-Preferred-Username header)"
+	}
+
+	s3Backend, ok := storageBackend.(*s3Storage)
+	if !ok {
+		return echo.NewHTTPError(http.StatusNotImplemented, "Presigned URLs are not supported by the active storage driver.")
+	}
+
+	key := c.Param("*")
+
+	info, err := storageBackend.Stat(c.Request().Context(), key)
+	if err != nil {
+		if errors.Is(err, ErrNotExist) {
+			return echo.NewHTTPError(http.StatusNotFound, fmt.Sprintf("File '%s' not found.", key))
+		}
+		log.Printf("Error statting object '%s' for user %s: %v", key, userPreferredUsername, err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Could not create presigned download URL.")
+	}
+	if err := checkReadAccess(info, userPreferredUsername, isAdminRequest(c)); err != nil {
+		return echo.NewHTTPError(http.StatusForbidden, "You do not have access to this file.")
+	}
+
+	expiresIn, _ := strconv.Atoi(c.QueryParam("expires_in"))
+	ttl := clampPresignExpiry(expiresIn)
+
+	url, err := s3Backend.PresignGet(c.Request().Context(), key, ttl)
+	if err != nil {
+		log.Printf("Failed to create presigned download URL for '%s' by user %s: %v", key, userPreferredUsername, err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Could not create presigned download URL.")
+	}
+
+	log.Printf("Presigned download URL issued for '%s' (expires in %v) by user: %s", key, ttl, userPreferredUsername)
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"url":        url,
+		"method":     http.MethodGet,
+		"headers":    map[string]string{},
+		"expires_at": time.Now().Add(ttl).UTC().Format(time.RFC3339),
+	})
+}
+
+// clampPresignExpiry applies the default/maximum expiry bounds to a client-requested expiry in seconds.
+func clampPresignExpiry(requestedSeconds int) time.Duration {
+	seconds := requestedSeconds
+	if seconds <= 0 {
+		seconds = defaultPresignExpirySeconds
+	}
+	if seconds > maxPresignExpirySeconds {
+		seconds = maxPresignExpirySeconds
+	}
+	return time.Duration(seconds) * time.Second
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+// ObjectInfo describes a stored object, independent of which Storage
+// implementation is backing it.
+type ObjectInfo struct {
+	Key          string
+	Size         int64
+	ContentType  string
+	ETag         string
+	LastModified time.Time
+	Metadata     map[string]string
+}
+
+// PutObjectInput carries everything a Storage implementation needs to store an object.
+type PutObjectInput struct {
+	Key         string
+	Body        io.Reader
+	ContentType string
+	Metadata    map[string]string
+}
+
+// PartInfo describes one part of an in-progress multipart upload.
+type PartInfo struct {
+	PartNumber int64
+	ETag       string
+	Size       int64
+}
+
+// CompletedPart identifies one uploaded part when finalizing a multipart upload.
+type CompletedPart struct {
+	PartNumber int64
+	ETag       string
+}
+
+// Storage abstracts the object storage backend used by the file handlers, so
+// the service can run against S3/MinIO in production or a local disk
+// directory in dev/CI without the handlers knowing the difference.
+type Storage interface {
+	// Put stores an object and returns its resulting metadata.
+	Put(ctx context.Context, input PutObjectInput) (*ObjectInfo, error)
+	// Get opens an object for reading. Callers must close the returned reader.
+	Get(ctx context.Context, key string) (io.ReadCloser, *ObjectInfo, error)
+	// Stat returns metadata for an object without reading its body.
+	Stat(ctx context.Context, key string) (*ObjectInfo, error)
+	// Delete removes an object. Deleting a missing key is not an error.
+	Delete(ctx context.Context, key string) error
+	// List returns the key, size and modification time of every object whose
+	// key starts with prefix; ContentType and Metadata are left empty.
+	List(ctx context.Context, prefix string) ([]ObjectInfo, error)
+	// SetMetadata replaces an object's metadata in place, without re-uploading its body.
+	SetMetadata(ctx context.Context, key string, metadata map[string]string) error
+
+	// InitMultipart begins a multipart upload for key and returns an upload ID.
+	InitMultipart(ctx context.Context, key, contentType string, metadata map[string]string) (uploadID string, err error)
+	// UploadPart uploads a single part of a multipart upload and returns its ETag.
+	UploadPart(ctx context.Context, key, uploadID string, partNumber int64, body io.Reader) (etag string, err error)
+	// ListParts lists the parts already received for a multipart upload.
+	ListParts(ctx context.Context, key, uploadID string) ([]PartInfo, error)
+	// CompleteMultipart finalizes a multipart upload from its ordered part list.
+	CompleteMultipart(ctx context.Context, key, uploadID string, parts []CompletedPart) error
+	// AbortMultipart cancels a multipart upload and releases any parts already received.
+	AbortMultipart(ctx context.Context, key, uploadID string) error
+}
+
+// ErrNotExist is returned by Get/Stat when the requested key does not exist.
+var ErrNotExist = errNotExist{}
+
+type errNotExist struct{}
+
+func (errNotExist) Error() string { return "object does not exist" }
@@ -0,0 +1,402 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// localStorage implements Storage on a local filesystem directory. It exists
+// so the service can run in dev/CI or on a single node without any S3
+// dependency, selected via STORAGE_DRIVER=local.
+type localStorage struct {
+	root         string
+	maxDiskBytes int64
+	mu           sync.Mutex
+}
+
+// newLocalStorage returns a Storage rooted at dir. Put rejects an object that
+// would take the directory past maxDiskBytes (0 means unlimited); multipart
+// parts and completed multipart objects are not checked against it.
+func newLocalStorage(dir string, maxDiskBytes int64) (*localStorage, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create storage root %q: %w", dir, err)
+	}
+	if err := os.MkdirAll(filepath.Join(dir, ".multipart"), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create multipart staging dir: %w", err)
+	}
+	return &localStorage{root: dir, maxDiskBytes: maxDiskBytes}, nil
+}
+
+// localMeta is the sidecar JSON stored alongside each object to carry the
+// metadata that S3 would otherwise attach to the object itself.
+type localMeta struct {
+	ContentType string            `json:"content_type"`
+	Metadata    map[string]string `json:"metadata,omitempty"`
+}
+
+func (l *localStorage) objectPath(key string) (string, error) {
+	clean := filepath.Clean("/" + key)[1:]
+	if clean == "" || clean == "." || strings.HasPrefix(clean, "..") {
+		return "", fmt.Errorf("invalid object key %q", key)
+	}
+	return filepath.Join(l.root, clean), nil
+}
+
+func (l *localStorage) metaPath(objectPath string) string {
+	return objectPath + ".meta.json"
+}
+
+func (l *localStorage) Put(ctx context.Context, input PutObjectInput) (*ObjectInfo, error) {
+	path, err := l.objectPath(input.Key)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".upload-*")
+	if err != nil {
+		return nil, err
+	}
+	tmpName := tmp.Name()
+	defer os.Remove(tmpName)
+
+	written, err := io.Copy(tmp, input.Body)
+	if err != nil {
+		tmp.Close()
+		return nil, err
+	}
+
+	l.mu.Lock()
+	if l.maxDiskBytes > 0 {
+		used, usageErr := l.diskUsage()
+		if usageErr != nil {
+			l.mu.Unlock()
+			tmp.Close()
+			return nil, usageErr
+		}
+		// diskUsage already counts the temp file holding this upload.
+		used -= written
+		if used+written > l.maxDiskBytes {
+			l.mu.Unlock()
+			tmp.Close()
+			return nil, fmt.Errorf("storage quota exceeded: %d bytes used, %d requested, %d byte limit", used, written, l.maxDiskBytes)
+		}
+	}
+	l.mu.Unlock()
+
+	if err := tmp.Close(); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(tmpName, path); err != nil {
+		return nil, err
+	}
+
+	meta := localMeta{ContentType: input.ContentType, Metadata: input.Metadata}
+	metaBytes, err := json.Marshal(meta)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(l.metaPath(path), metaBytes, 0o644); err != nil {
+		return nil, err
+	}
+
+	return l.Stat(ctx, input.Key)
+}
+
+func (l *localStorage) Get(ctx context.Context, key string) (io.ReadCloser, *ObjectInfo, error) {
+	path, err := l.objectPath(key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil, ErrNotExist
+		}
+		return nil, nil, err
+	}
+
+	info, err := l.Stat(ctx, key)
+	if err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+	return f, info, nil
+}
+
+func (l *localStorage) Stat(ctx context.Context, key string) (*ObjectInfo, error) {
+	path, err := l.objectPath(key)
+	if err != nil {
+		return nil, err
+	}
+
+	fi, err := os.Stat(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrNotExist
+		}
+		return nil, err
+	}
+
+	info := &ObjectInfo{
+		Key:          key,
+		Size:         fi.Size(),
+		LastModified: fi.ModTime(),
+	}
+
+	if metaBytes, err := os.ReadFile(l.metaPath(path)); err == nil {
+		var meta localMeta
+		if jsonErr := json.Unmarshal(metaBytes, &meta); jsonErr == nil {
+			info.ContentType = meta.ContentType
+			info.Metadata = meta.Metadata
+		}
+	}
+	return info, nil
+}
+
+func (l *localStorage) Delete(ctx context.Context, key string) error {
+	path, err := l.objectPath(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	os.Remove(l.metaPath(path))
+	return nil
+}
+
+func (l *localStorage) List(ctx context.Context, prefix string) ([]ObjectInfo, error) {
+	var objects []ObjectInfo
+	err := filepath.Walk(l.root, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if fi.IsDir() || strings.HasPrefix(path, filepath.Join(l.root, ".multipart")) {
+			return nil
+		}
+		if strings.HasSuffix(path, ".meta.json") {
+			return nil
+		}
+
+		rel, err := filepath.Rel(l.root, path)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(rel)
+		if prefix != "" && !strings.HasPrefix(key, prefix) {
+			return nil
+		}
+
+		objects = append(objects, ObjectInfo{
+			Key:          key,
+			Size:         fi.Size(),
+			LastModified: fi.ModTime(),
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return objects, nil
+}
+
+// diskUsage walks the storage root and sums the size of every file under it,
+// including metadata sidecars, staged multipart parts and in-progress temp files.
+// Callers must hold l.mu.
+func (l *localStorage) diskUsage() (int64, error) {
+	var total int64
+	err := filepath.Walk(l.root, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !fi.IsDir() {
+			total += fi.Size()
+		}
+		return nil
+	})
+	return total, err
+}
+
+// --- Multipart support -----------------------------------------------------
+//
+// Local disk has no native multipart API, so InitMultipart stages parts as
+// individual files under root/.multipart/<uploadID>/ and CompleteMultipart
+// concatenates them in order into the final object, mirroring what S3 does
+// server-side.
+
+func (l *localStorage) multipartDir(uploadID string) string {
+	return filepath.Join(l.root, ".multipart", uploadID)
+}
+
+func (l *localStorage) SetMetadata(ctx context.Context, key string, metadata map[string]string) error {
+	path, err := l.objectPath(key)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(path); err != nil {
+		if os.IsNotExist(err) {
+			return ErrNotExist
+		}
+		return err
+	}
+
+	existing, err := l.Stat(ctx, key)
+	if err != nil {
+		return err
+	}
+
+	metaBytes, err := json.Marshal(localMeta{ContentType: existing.ContentType, Metadata: metadata})
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(l.metaPath(path), metaBytes, 0o644)
+}
+
+func (l *localStorage) InitMultipart(ctx context.Context, key, contentType string, metadata map[string]string) (string, error) {
+	uploadID := uuid.New().String()
+	dir := l.multipartDir(uploadID)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+
+	meta := localMeta{ContentType: contentType, Metadata: metadata}
+	metaBytes, err := json.Marshal(meta)
+	if err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(filepath.Join(dir, "meta.json"), metaBytes, 0o644); err != nil {
+		return "", err
+	}
+	return uploadID, nil
+}
+
+func (l *localStorage) UploadPart(ctx context.Context, key, uploadID string, partNumber int64, body io.Reader) (string, error) {
+	dir := l.multipartDir(uploadID)
+	if _, err := os.Stat(dir); err != nil {
+		return "", fmt.Errorf("unknown upload ID %q", uploadID)
+	}
+
+	partPath := filepath.Join(dir, fmt.Sprintf("part-%010d", partNumber))
+	f, err := os.Create(partPath)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	if _, err := io.Copy(f, body); err != nil {
+		return "", err
+	}
+	// The local driver has no server-side ETag concept, so it derives a
+	// stable one from the part's position; parity with S3 responses is not
+	// required beyond CompleteMultipart round-tripping what UploadPart returned.
+	return fmt.Sprintf("local-%s-%d", uploadID, partNumber), nil
+}
+
+func (l *localStorage) ListParts(ctx context.Context, key, uploadID string) ([]PartInfo, error) {
+	dir := l.multipartDir(uploadID)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("unknown upload ID %q", uploadID)
+	}
+
+	var parts []PartInfo
+	for _, entry := range entries {
+		if !strings.HasPrefix(entry.Name(), "part-") {
+			continue
+		}
+		partNumber, err := strconv.ParseInt(strings.TrimPrefix(entry.Name(), "part-"), 10, 64)
+		if err != nil {
+			continue
+		}
+		fi, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		parts = append(parts, PartInfo{
+			PartNumber: partNumber,
+			ETag:       fmt.Sprintf("local-%s-%d", uploadID, partNumber),
+			Size:       fi.Size(),
+		})
+	}
+	sort.Slice(parts, func(i, j int) bool { return parts[i].PartNumber < parts[j].PartNumber })
+	return parts, nil
+}
+
+func (l *localStorage) CompleteMultipart(ctx context.Context, key, uploadID string, parts []CompletedPart) error {
+	dir := l.multipartDir(uploadID)
+	metaBytes, err := os.ReadFile(filepath.Join(dir, "meta.json"))
+	if err != nil {
+		return fmt.Errorf("unknown upload ID %q", uploadID)
+	}
+	var meta localMeta
+	if err := json.Unmarshal(metaBytes, &meta); err != nil {
+		return err
+	}
+
+	path, err := l.objectPath(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".upload-*")
+	if err != nil {
+		return err
+	}
+	tmpName := tmp.Name()
+	defer os.Remove(tmpName)
+
+	for _, p := range parts {
+		partPath := filepath.Join(dir, fmt.Sprintf("part-%010d", p.PartNumber))
+		partFile, err := os.Open(partPath)
+		if err != nil {
+			tmp.Close()
+			return fmt.Errorf("missing part %d: %w", p.PartNumber, err)
+		}
+		_, err = io.Copy(tmp, partFile)
+		partFile.Close()
+		if err != nil {
+			tmp.Close()
+			return err
+		}
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpName, path); err != nil {
+		return err
+	}
+
+	metaOut, err := json.Marshal(localMeta{ContentType: meta.ContentType, Metadata: meta.Metadata})
+	if err == nil {
+		os.WriteFile(l.metaPath(path), metaOut, 0o644)
+	}
+
+	os.RemoveAll(dir)
+	return nil
+}
+
+func (l *localStorage) AbortMultipart(ctx context.Context, key, uploadID string) error {
+	dir := l.multipartDir(uploadID)
+	if _, err := os.Stat(dir); err != nil {
+		return errors.New("unknown upload ID")
+	}
+	return os.RemoveAll(dir)
+}
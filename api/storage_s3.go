@@ -0,0 +1,323 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+)
+
+// s3Storage implements Storage on top of an S3-compatible bucket (AWS S3 or MinIO).
+type s3Storage struct {
+	client   *s3.S3
+	uploader *s3manager.Uploader
+	bucket   string
+}
+
+// newS3Storage builds a Storage backed by the given S3 client, uploader and bucket.
+func newS3Storage(client *s3.S3, uploader *s3manager.Uploader, bucket string) *s3Storage {
+	return &s3Storage{client: client, uploader: uploader, bucket: bucket}
+}
+
+func (s *s3Storage) Put(ctx context.Context, input PutObjectInput) (*ObjectInfo, error) {
+	uploadInput := &s3manager.UploadInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(input.Key),
+		Body:   input.Body,
+	}
+	if input.ContentType != "" {
+		uploadInput.ContentType = aws.String(input.ContentType)
+	}
+	if len(input.Metadata) > 0 {
+		uploadInput.Metadata = aws.StringMap(input.Metadata)
+	}
+
+	if _, err := s.uploader.UploadWithContext(ctx, uploadInput); err != nil {
+		return nil, err
+	}
+	return s.Stat(ctx, input.Key)
+}
+
+func (s *s3Storage) Get(ctx context.Context, key string) (io.ReadCloser, *ObjectInfo, error) {
+	result, err := s.client.GetObjectWithContext(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		if isS3NotFound(err) {
+			return nil, nil, ErrNotExist
+		}
+		return nil, nil, err
+	}
+
+	info := &ObjectInfo{
+		Key:          key,
+		Size:         aws.Int64Value(result.ContentLength),
+		ContentType:  aws.StringValue(result.ContentType),
+		ETag:         aws.StringValue(result.ETag),
+		LastModified: aws.TimeValue(result.LastModified),
+		Metadata:     metadataFromS3(result.Metadata),
+	}
+	return result.Body, info, nil
+}
+
+func (s *s3Storage) Stat(ctx context.Context, key string) (*ObjectInfo, error) {
+	result, err := s.client.HeadObjectWithContext(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		if isS3NotFound(err) {
+			return nil, ErrNotExist
+		}
+		return nil, err
+	}
+
+	return &ObjectInfo{
+		Key:          key,
+		Size:         aws.Int64Value(result.ContentLength),
+		ContentType:  aws.StringValue(result.ContentType),
+		ETag:         aws.StringValue(result.ETag),
+		LastModified: aws.TimeValue(result.LastModified),
+		Metadata:     metadataFromS3(result.Metadata),
+	}, nil
+}
+
+// metadataFromS3 lower-cases the user-metadata keys the SDK returns in
+// canonical header form ("Owner"), so lookups by the lower-case metaKey*
+// constants match on every backend.
+func metadataFromS3(m map[string]*string) map[string]string {
+	out := make(map[string]string, len(m))
+	for k, v := range m {
+		out[strings.ToLower(k)] = aws.StringValue(v)
+	}
+	return out
+}
+
+func (s *s3Storage) Delete(ctx context.Context, key string) error {
+	_, err := s.client.DeleteObjectWithContext(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	return err
+}
+
+func (s *s3Storage) List(ctx context.Context, prefix string) ([]ObjectInfo, error) {
+	input := &s3.ListObjectsV2Input{
+		Bucket: aws.String(s.bucket),
+	}
+	if prefix != "" {
+		input.Prefix = aws.String(prefix)
+	}
+
+	var objects []ObjectInfo
+	err := s.client.ListObjectsV2PagesWithContext(ctx, input, func(page *s3.ListObjectsV2Output, lastPage bool) bool {
+		for _, obj := range page.Contents {
+			if obj.Key == nil {
+				continue
+			}
+			objects = append(objects, ObjectInfo{
+				Key:          *obj.Key,
+				Size:         aws.Int64Value(obj.Size),
+				ETag:         aws.StringValue(obj.ETag),
+				LastModified: aws.TimeValue(obj.LastModified),
+			})
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return objects, nil
+}
+
+func (s *s3Storage) SetMetadata(ctx context.Context, key string, metadata map[string]string) error {
+	existing, err := s.Stat(ctx, key)
+	if err != nil {
+		return err
+	}
+
+	copyInput := &s3.CopyObjectInput{
+		Bucket:            aws.String(s.bucket),
+		Key:               aws.String(key),
+		CopySource:        aws.String(fmt.Sprintf("%s/%s", s.bucket, encodeCopySourceKey(key))),
+		MetadataDirective: aws.String(s3.MetadataDirectiveReplace),
+		Metadata:          aws.StringMap(metadata),
+	}
+	if existing.ContentType != "" {
+		copyInput.ContentType = aws.String(existing.ContentType)
+	}
+
+	_, err = s.client.CopyObjectWithContext(ctx, copyInput)
+	return err
+}
+
+// encodeCopySourceKey URL-encodes each segment of key for use in the
+// x-amz-copy-source header, which the SDK sends verbatim without encoding.
+// Path separators are preserved so the source is still addressed as a single
+// object rather than one whose slashes are themselves escaped.
+func encodeCopySourceKey(key string) string {
+	segments := strings.Split(key, "/")
+	for i, seg := range segments {
+		segments[i] = url.PathEscape(seg)
+	}
+	return strings.Join(segments, "/")
+}
+
+func (s *s3Storage) InitMultipart(ctx context.Context, key, contentType string, metadata map[string]string) (string, error) {
+	input := &s3.CreateMultipartUploadInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	}
+	if contentType != "" {
+		input.ContentType = aws.String(contentType)
+	}
+	if len(metadata) > 0 {
+		input.Metadata = aws.StringMap(metadata)
+	}
+
+	result, err := s.client.CreateMultipartUploadWithContext(ctx, input)
+	if err != nil {
+		return "", err
+	}
+	return aws.StringValue(result.UploadId), nil
+}
+
+func (s *s3Storage) UploadPart(ctx context.Context, key, uploadID string, partNumber int64, body io.Reader) (string, error) {
+	readSeeker, err := toReadSeeker(body)
+	if err != nil {
+		return "", err
+	}
+
+	result, err := s.client.UploadPartWithContext(ctx, &s3.UploadPartInput{
+		Bucket:     aws.String(s.bucket),
+		Key:        aws.String(key),
+		UploadId:   aws.String(uploadID),
+		PartNumber: aws.Int64(partNumber),
+		Body:       readSeeker,
+	})
+	if err != nil {
+		return "", err
+	}
+	return aws.StringValue(result.ETag), nil
+}
+
+func (s *s3Storage) ListParts(ctx context.Context, key, uploadID string) ([]PartInfo, error) {
+	var parts []PartInfo
+	input := &s3.ListPartsInput{
+		Bucket:   aws.String(s.bucket),
+		Key:      aws.String(key),
+		UploadId: aws.String(uploadID),
+	}
+	err := s.client.ListPartsPagesWithContext(ctx, input, func(page *s3.ListPartsOutput, lastPage bool) bool {
+		for _, p := range page.Parts {
+			parts = append(parts, PartInfo{
+				PartNumber: aws.Int64Value(p.PartNumber),
+				ETag:       aws.StringValue(p.ETag),
+				Size:       aws.Int64Value(p.Size),
+			})
+		}
+		return true
+	})
+	return parts, err
+}
+
+func (s *s3Storage) CompleteMultipart(ctx context.Context, key, uploadID string, parts []CompletedPart) error {
+	completedParts := make([]*s3.CompletedPart, len(parts))
+	for i, p := range parts {
+		completedParts[i] = &s3.CompletedPart{
+			PartNumber: aws.Int64(p.PartNumber),
+			ETag:       aws.String(p.ETag),
+		}
+	}
+
+	_, err := s.client.CompleteMultipartUploadWithContext(ctx, &s3.CompleteMultipartUploadInput{
+		Bucket:          aws.String(s.bucket),
+		Key:             aws.String(key),
+		UploadId:        aws.String(uploadID),
+		MultipartUpload: &s3.CompletedMultipartUpload{Parts: completedParts},
+	})
+	return err
+}
+
+func (s *s3Storage) AbortMultipart(ctx context.Context, key, uploadID string) error {
+	_, err := s.client.AbortMultipartUploadWithContext(ctx, &s3.AbortMultipartUploadInput{
+		Bucket:   aws.String(s.bucket),
+		Key:      aws.String(key),
+		UploadId: aws.String(uploadID),
+	})
+	return err
+}
+
+// PresignPut returns a time-limited URL that a client can PUT an object's
+// bytes to directly against S3, without proxying through this service. If
+// contentLength is > 0, or metadata is non-empty, the client must send
+// matching Content-Length/x-amz-meta-* headers or the upload will be
+// rejected, since they become part of the signed request.
+func (s *s3Storage) PresignPut(ctx context.Context, key, contentType string, contentLength int64, metadata map[string]string, ttl time.Duration) (string, map[string]string, error) {
+	input := &s3.PutObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	}
+	if contentType != "" {
+		input.ContentType = aws.String(contentType)
+	}
+	if contentLength > 0 {
+		input.ContentLength = aws.Int64(contentLength)
+	}
+	if len(metadata) > 0 {
+		input.Metadata = aws.StringMap(metadata)
+	}
+
+	req, _ := s.client.PutObjectRequest(input)
+	req.SetContext(ctx)
+	url, err := req.Presign(ttl)
+	if err != nil {
+		return "", nil, err
+	}
+
+	headers := map[string]string{}
+	if contentType != "" {
+		headers["Content-Type"] = contentType
+	}
+	for k, v := range metadata {
+		headers["X-Amz-Meta-"+k] = v
+	}
+	return url, headers, nil
+}
+
+// PresignGet returns a time-limited URL that a client can GET an object's bytes from directly against S3.
+func (s *s3Storage) PresignGet(ctx context.Context, key string, ttl time.Duration) (string, error) {
+	req, _ := s.client.GetObjectRequest(&s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	req.SetContext(ctx)
+	return req.Presign(ttl)
+}
+
+func isS3NotFound(err error) bool {
+	aerr, ok := err.(awserr.Error)
+	return ok && (aerr.Code() == s3.ErrCodeNoSuchKey || aerr.Code() == "NotFound")
+}
+
+// toReadSeeker buffers body into memory if it isn't already an io.ReadSeeker,
+// since the S3 SDK needs to seek to retry a part upload.
+func toReadSeeker(body io.Reader) (io.ReadSeeker, error) {
+	if rs, ok := body.(io.ReadSeeker); ok {
+		return rs, nil
+	}
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewReader(data), nil
+}
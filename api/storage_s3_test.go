@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+func newTestS3Storage(t *testing.T) *s3Storage {
+	t.Helper()
+	sess := session.Must(session.NewSession(&aws.Config{
+		Region:      aws.String("us-east-1"),
+		Credentials: credentials.NewStaticCredentials("test-key", "test-secret", ""),
+	}))
+	return newS3Storage(s3.New(sess), nil, "test-bucket")
+}
+
+// signedHeaders returns the lowercased list of header names S3 signed into
+// the presigned URL's X-Amz-SignedHeaders query parameter.
+func signedHeaders(t *testing.T, rawURL string) []string {
+	t.Helper()
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		t.Fatalf("parsing presigned URL: %v", err)
+	}
+	return strings.Split(parsed.Query().Get("X-Amz-SignedHeaders"), ";")
+}
+
+// TestPresignPut_OmittedContentLength guards against regressing to a
+// presigned PUT that signs a placeholder Content-Length: a real upload
+// against such a URL must send that exact byte count or S3 responds with
+// SignatureDoesNotMatch, which defeats "I don't know the size yet" callers.
+func TestPresignPut_OmittedContentLength(t *testing.T) {
+	s := newTestS3Storage(t)
+
+	rawURL, _, err := s.PresignPut(context.Background(), "users/alice/foo.bin", "", 0, nil, 15*time.Minute)
+	if err != nil {
+		t.Fatalf("PresignPut: %v", err)
+	}
+
+	for _, h := range signedHeaders(t, rawURL) {
+		if h == "content-length" {
+			t.Fatalf("expected content-length to not be signed when contentLength is omitted, got signed headers %v", signedHeaders(t, rawURL))
+		}
+	}
+}
+
+// TestPresignPut_DeclaredContentLength verifies that a caller who does
+// declare a size still gets it enforced via the signed Content-Length header.
+func TestPresignPut_DeclaredContentLength(t *testing.T) {
+	s := newTestS3Storage(t)
+
+	rawURL, _, err := s.PresignPut(context.Background(), "users/alice/foo.bin", "", 1024, nil, 15*time.Minute)
+	if err != nil {
+		t.Fatalf("PresignPut: %v", err)
+	}
+
+	found := false
+	for _, h := range signedHeaders(t, rawURL) {
+		if h == "content-length" {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatalf("expected content-length to be signed when contentLength is declared, got signed headers %v", signedHeaders(t, rawURL))
+	}
+}
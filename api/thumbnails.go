@@ -0,0 +1,249 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"
+	"image/jpeg"
+	_ "image/png"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	thumbnailWidthPx      = 120
+	previewWidthPx        = 1024
+	thumbnailJPEGQuality  = 85
+	derivativeWorkerLimit = 4
+)
+
+// thumbnailWorkers bounds how many derivative-generation goroutines can run
+// at once, so a burst of large image uploads can't exhaust memory or CPU.
+var thumbnailWorkers = make(chan struct{}, derivativeWorkerLimit)
+
+func thumbnailKey(key string) string { return key + "_thumb.jpg" }
+func previewKey(key string) string   { return key + "_preview.jpg" }
+func isImageKey(key string) bool     { return imageExtensions[strings.ToLower(extOf(key))] }
+
+// isDerivativeKey reports whether key names a thumbnail/preview generated by
+// generateDerivatives, rather than an originally uploaded object. Derivatives
+// are stored as regular objects alongside the original, so callers that list
+// or enumerate objects for deletion need to filter them out explicitly.
+func isDerivativeKey(key string) bool {
+	return strings.HasSuffix(key, "_thumb.jpg") || strings.HasSuffix(key, "_preview.jpg")
+}
+func extOf(key string) string {
+	if i := strings.LastIndexByte(key, '.'); i != -1 {
+		return key[i:]
+	}
+	return ""
+}
+
+var imageExtensions = map[string]bool{
+	".jpg": true, ".jpeg": true, ".png": true, ".gif": true, ".webp": true,
+}
+
+// maybeGenerateDerivativesAsync starts a background goroutine that waits for
+// a slot in thumbnailWorkers, reads the newly uploaded object, sniffs its
+// first 512 bytes and, if it looks like an image, builds a thumbnail and a
+// preview and uploads both alongside the original. Upload requests are not
+// delayed waiting for this to finish.
+func maybeGenerateDerivativesAsync(key, username string) {
+	go func() {
+		thumbnailWorkers <- struct{}{}
+		defer func() { <-thumbnailWorkers }()
+
+		ctx := context.Background()
+		if err := generateDerivatives(ctx, key); err != nil {
+			if !errors.Is(err, errNotAnImage) {
+				log.Printf("Failed to generate derivatives for '%s' (uploaded by %s): %v", key, username, err)
+			}
+			return
+		}
+		log.Printf("Generated thumbnail/preview for '%s' (uploaded by %s)", key, username)
+	}()
+}
+
+var errNotAnImage = errors.New("not an image")
+
+// generateDerivatives reads the object at key, and if it sniffs as a
+// supported image type, stores a thumbnail at most 120px wide and a preview
+// at most 1024px wide next to it.
+func generateDerivatives(ctx context.Context, key string) error {
+	rc, origInfo, err := storageBackend.Get(ctx, key)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	derivativeMetadata := map[string]string{metaKeyOwner: origInfo.Metadata[metaKeyOwner]}
+
+	data, err := io.ReadAll(rc)
+	if err != nil {
+		return err
+	}
+
+	sniffLen := 512
+	if len(data) < sniffLen {
+		sniffLen = len(data)
+	}
+	if !isImageContentType(http.DetectContentType(data[:sniffLen])) {
+		return errNotAnImage
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("decoding image: %w", err)
+	}
+
+	thumb, err := encodeJPEGThumbnail(src, thumbnailWidthPx)
+	if err != nil {
+		return fmt.Errorf("encoding thumbnail: %w", err)
+	}
+	if _, err := storageBackend.Put(ctx, PutObjectInput{
+		Key: thumbnailKey(key), Body: bytes.NewReader(thumb), ContentType: "image/jpeg", Metadata: derivativeMetadata,
+	}); err != nil {
+		return fmt.Errorf("uploading thumbnail: %w", err)
+	}
+
+	preview, err := encodeJPEGThumbnail(src, previewWidthPx)
+	if err != nil {
+		return fmt.Errorf("encoding preview: %w", err)
+	}
+	if _, err := storageBackend.Put(ctx, PutObjectInput{
+		Key: previewKey(key), Body: bytes.NewReader(preview), ContentType: "image/jpeg", Metadata: derivativeMetadata,
+	}); err != nil {
+		return fmt.Errorf("uploading preview: %w", err)
+	}
+
+	return nil
+}
+
+// encodeJPEGThumbnail resamples src to targetWidth wide (an image already that narrow or narrower keeps its width), preserving aspect ratio, and encodes it as a JPEG.
+func encodeJPEGThumbnail(src image.Image, targetWidth int) ([]byte, error) {
+	bounds := src.Bounds()
+	srcWidth, srcHeight := bounds.Dx(), bounds.Dy()
+	if srcWidth <= targetWidth {
+		targetWidth = srcWidth
+	}
+	targetHeight := int(float64(srcHeight) * float64(targetWidth) / float64(srcWidth))
+	if targetHeight < 1 {
+		targetHeight = 1
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, targetWidth, targetHeight))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, bounds, draw.Over, nil)
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, dst, &jpeg.Options{Quality: thumbnailJPEGQuality}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+var sniffableImageTypes = []string{"image/jpeg", "image/png", "image/gif", "image/webp"}
+
+func isImageContentType(contentType string) bool {
+	for _, t := range sniffableImageTypes {
+		if strings.HasPrefix(contentType, t) {
+			return true
+		}
+	}
+	return false
+}
+
+// getThumbnailHandler streams the thumbnail derivative for an uploaded image, generating it on demand if it isn't cached yet.
+// @Summary Get a file's thumbnail
+// @Description Streams a JPEG thumbnail at most 120px wide for a previously uploaded image, generating it on demand if needed.
+// @Tags Files
+// @Produce image/jpeg
+// @Param filename path string true "Key of the original file"
+// @Success 200 {file} byte "Thumbnail image"
+// @Failure 403 {object} map[string]string "detail: You do not have access to this file."
+// @Failure 404 {object} map[string]string "detail: File not found or not an image."
+// @Failure 500 {object} map[string]string "detail: Failed to generate derivative."
+// @Router /files/thumb/{filename} [get]
+func getThumbnailHandler(c echo.Context) error {
+	return serveDerivative(c, thumbnailKey)
+}
+
+// getPreviewHandler streams the 1024px preview derivative for an uploaded image, generating it on demand if needed.
+// @Summary Get a file's preview
+// @Description Streams a JPEG preview at most 1024px wide for a previously uploaded image, generating it on demand if needed.
+// @Tags Files
+// @Produce image/jpeg
+// @Param filename path string true "Key of the original file"
+// @Success 200 {file} byte "Preview image"
+// @Failure 403 {object} map[string]string "detail: You do not have access to this file."
+// @Failure 404 {object} map[string]string "detail: File not found or not an image."
+// @Failure 500 {object} map[string]string "detail: Failed to generate derivative."
+// @Router /files/preview/{filename} [get]
+func getPreviewHandler(c echo.Context) error {
+	return serveDerivative(c, previewKey)
+}
+
+func serveDerivative(c echo.Context, deriveKey func(string) string) error {
+	filename := c.Param("*")
+	ctx := c.Request().Context()
+
+	userPreferredUsername := c.Request().Header.Get("X-User-Preferred-Username")
+	if userPreferredUsername == "" {
+		userPreferredUsername = "N/A (no X-User-Preferred-Username header)"
+	}
+
+	info, err := storageBackend.Stat(ctx, filename)
+	if err != nil {
+		if errors.Is(err, ErrNotExist) {
+			return echo.NewHTTPError(http.StatusNotFound, fmt.Sprintf("File '%s' not found or not an image.", filename))
+		}
+		log.Printf("Error statting '%s' for derivative request: %v", filename, err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to retrieve derivative.")
+	}
+	if err := checkReadAccess(info, userPreferredUsername, isAdminRequest(c)); err != nil {
+		return echo.NewHTTPError(http.StatusForbidden, "You do not have access to this file.")
+	}
+
+	derivedKey := deriveKey(filename)
+
+	rc, derivInfo, err := storageBackend.Get(ctx, derivedKey)
+	if err != nil {
+		if !errors.Is(err, ErrNotExist) {
+			log.Printf("Error getting derivative '%s' from storage: %v", derivedKey, err)
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to retrieve derivative.")
+		}
+
+		if genErr := generateDerivatives(ctx, filename); genErr != nil {
+			if errors.Is(genErr, errNotAnImage) || errors.Is(genErr, ErrNotExist) {
+				return echo.NewHTTPError(http.StatusNotFound, fmt.Sprintf("File '%s' not found or not an image.", filename))
+			}
+			log.Printf("Error generating derivative for '%s': %v", filename, genErr)
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to generate derivative.")
+		}
+
+		rc, derivInfo, err = storageBackend.Get(ctx, derivedKey)
+		if err != nil {
+			log.Printf("Error getting newly generated derivative '%s': %v", derivedKey, err)
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to retrieve derivative.")
+		}
+	}
+	defer rc.Close()
+
+	c.Response().Header().Set("Content-Type", "image/jpeg")
+	c.Response().Header().Set("Content-Length", fmt.Sprintf("%d", derivInfo.Size))
+	c.Response().Header().Set("Cache-Control", "public, max-age=604800")
+
+	if _, err := io.Copy(c.Response().Writer, rc); err != nil {
+		log.Printf("Error streaming derivative '%s' to client: %v", derivedKey, err)
+	}
+	return nil
+}